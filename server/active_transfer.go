@@ -0,0 +1,178 @@
+package server
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func init() {
+	commandsMap["PORT"] = &CommandDescription{Fn: handlePORT}
+	commandsMap["EPRT"] = &CommandDescription{Fn: handleEPRT}
+}
+
+// activeTransferHandler implements transferHandler for active mode: instead
+// of the server listening and the client connecting in (passive mode), we
+// dial back to the address the client gave us via PORT/EPRT.
+type activeTransferHandler struct {
+	network        string
+	addr           string
+	tls            bool
+	tlsConfig      *tls.Config
+	connectTimeout time.Duration
+	conn           net.Conn
+}
+
+// Open dials the client-advertised address and returns the resulting
+// connection, wrapping it in TLS when PROT P is active.
+func (h *activeTransferHandler) Open() (net.Conn, error) {
+	conn, err := net.DialTimeout(h.network, h.addr, h.connectTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	if h.tls {
+		conn = tls.Client(conn, h.tlsConfig)
+	}
+
+	h.conn = conn
+	return conn, nil
+}
+
+// Close closes the dialed connection, if any was established.
+func (h *activeTransferHandler) Close() error {
+	if h.conn == nil {
+		return nil
+	}
+	return h.conn.Close()
+}
+
+// handlePORT implements the classic PORT command (RFC 959): the client
+// gives us an IPv4 address and port as "h1,h2,h3,h4,p1,p2".
+func handlePORT(c *clientHandler) {
+	if c.daddy.settings.DisableActive {
+		c.writeMessage(502, "PORT command is disabled on this server")
+		return
+	}
+
+	addr, err := parsePORTParam(c.param)
+	if err != nil {
+		c.writeMessage(501, "Invalid PORT address: "+err.Error())
+		return
+	}
+
+	if err := c.validateActiveTarget(addr); err != nil {
+		c.writeMessage(501, err.Error())
+		return
+	}
+
+	c.transfer = &activeTransferHandler{
+		network:        "tcp",
+		addr:           addr,
+		tls:            c.transferTLS,
+		tlsConfig:      c.getTLSConfig(),
+		connectTimeout: time.Duration(c.daddy.settings.DataConnectTimeout) * time.Second,
+	}
+	c.writeMessage(200, "PORT command successful")
+}
+
+func parsePORTParam(param string) (string, error) {
+	parts := strings.Split(param, ",")
+	if len(parts) != 6 {
+		return "", fmt.Errorf("expected 6 comma-separated fields")
+	}
+
+	fields := make([]int, 6)
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 || n > 255 {
+			return "", fmt.Errorf("invalid field %q", p)
+		}
+		fields[i] = n
+	}
+
+	host := fmt.Sprintf("%d.%d.%d.%d", fields[0], fields[1], fields[2], fields[3])
+	port := fields[4]<<8 + fields[5]
+	return net.JoinHostPort(host, strconv.Itoa(port)), nil
+}
+
+// handleEPRT implements the protocol-agnostic EPRT command (RFC 2428): the
+// client gives us "|proto|addr|port|", where proto is 1 for IPv4 or 2 for
+// IPv6.
+func handleEPRT(c *clientHandler) {
+	if c.daddy.settings.DisableActive {
+		c.writeMessage(502, "EPRT command is disabled on this server")
+		return
+	}
+
+	network, addr, err := parseEPRTParam(c.param)
+	if err != nil {
+		c.writeMessage(501, "Invalid EPRT address: "+err.Error())
+		return
+	}
+
+	if err := c.validateActiveTarget(addr); err != nil {
+		c.writeMessage(501, err.Error())
+		return
+	}
+
+	c.transfer = &activeTransferHandler{
+		network:        network,
+		addr:           addr,
+		tls:            c.transferTLS,
+		tlsConfig:      c.getTLSConfig(),
+		connectTimeout: time.Duration(c.daddy.settings.DataConnectTimeout) * time.Second,
+	}
+	c.writeMessage(200, "EPRT command successful")
+}
+
+// validateActiveTarget guards against the classic FTP bounce attack: by
+// default, PORT/EPRT may only target the IP address the control connection
+// itself came from, so a client can't use this server to open arbitrary
+// TCP connections to third-party hosts. Operators who need to relax this
+// for a trusted, firewall-restricted deployment can opt out via
+// DisableActiveIPCheck.
+func (c *clientHandler) validateActiveTarget(addr string) error {
+	if c.daddy.settings.DisableActiveIPCheck {
+		return nil
+	}
+
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("invalid transfer address %q", addr)
+	}
+
+	remoteHost, _, err := net.SplitHostPort(c.RemoteAddr().String())
+	if err != nil {
+		return fmt.Errorf("could not determine remote address")
+	}
+
+	if host != remoteHost {
+		return fmt.Errorf("refusing to connect to %s: does not match client address %s", host, remoteHost)
+	}
+
+	return nil
+}
+
+func parseEPRTParam(param string) (string, string, error) {
+	parts := strings.Split(param, "|")
+	// "|1|1.2.3.4|2121|" splits into ["", "1", "1.2.3.4", "2121", ""]
+	if len(parts) != 5 {
+		return "", "", fmt.Errorf("expected delimited |proto|addr|port| form")
+	}
+
+	var network string
+	switch parts[1] {
+	case "1":
+		network = "tcp4"
+	case "2":
+		network = "tcp6"
+	default:
+		return "", "", fmt.Errorf("unsupported network protocol %q", parts[1])
+	}
+
+	return network, net.JoinHostPort(parts[2], parts[3]), nil
+}
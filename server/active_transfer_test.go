@@ -0,0 +1,100 @@
+package server
+
+import (
+	"net"
+	"testing"
+)
+
+func TestParsePORTParam(t *testing.T) {
+	cases := []struct {
+		name    string
+		param   string
+		want    string
+		wantErr bool
+	}{
+		{name: "valid", param: "192,168,1,2,20,21", want: "192.168.1.2:5141"},
+		{name: "too few fields", param: "192,168,1,2,20", wantErr: true},
+		{name: "non numeric field", param: "192,168,1,2,20,x", wantErr: true},
+		{name: "field out of range", param: "192,168,1,256,20,21", wantErr: true},
+		{name: "negative field", param: "192,168,1,-1,20,21", wantErr: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := parsePORTParam(tc.param)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("parsePORTParam(%q) = %q, want error", tc.param, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parsePORTParam(%q) returned error: %v", tc.param, err)
+			}
+			if got != tc.want {
+				t.Fatalf("parsePORTParam(%q) = %q, want %q", tc.param, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseEPRTParam(t *testing.T) {
+	cases := []struct {
+		name        string
+		param       string
+		wantNetwork string
+		wantAddr    string
+		wantErr     bool
+	}{
+		{name: "ipv4", param: "|1|132.235.1.2|6275|", wantNetwork: "tcp4", wantAddr: "132.235.1.2:6275"},
+		{name: "ipv6", param: "|2|::1|6275|", wantNetwork: "tcp6", wantAddr: "[::1]:6275"},
+		{name: "unsupported protocol", param: "|3|132.235.1.2|6275|", wantErr: true},
+		{name: "missing delimiters", param: "1|132.235.1.2|6275", wantErr: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			network, addr, err := parseEPRTParam(tc.param)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("parseEPRTParam(%q) = (%q, %q), want error", tc.param, network, addr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseEPRTParam(%q) returned error: %v", tc.param, err)
+			}
+			if network != tc.wantNetwork || addr != tc.wantAddr {
+				t.Fatalf("parseEPRTParam(%q) = (%q, %q), want (%q, %q)", tc.param, network, addr, tc.wantNetwork, tc.wantAddr)
+			}
+		})
+	}
+}
+
+type fakeConn struct {
+	net.Conn
+	remote net.Addr
+}
+
+func (f fakeConn) RemoteAddr() net.Addr { return f.remote }
+
+func TestValidateActiveTarget(t *testing.T) {
+	remote := &net.TCPAddr{IP: net.ParseIP("203.0.113.5"), Port: 54321}
+	c := &clientHandler{
+		daddy: &FtpServer{settings: Settings{}},
+		conn:  fakeConn{remote: remote},
+	}
+
+	if err := c.validateActiveTarget("203.0.113.5:2121"); err != nil {
+		t.Fatalf("expected matching client address to be allowed, got error: %v", err)
+	}
+
+	if err := c.validateActiveTarget("198.51.100.9:2121"); err == nil {
+		t.Fatal("expected bounce attempt to a third-party host to be rejected")
+	}
+
+	c.daddy.settings.DisableActiveIPCheck = true
+	if err := c.validateActiveTarget("198.51.100.9:2121"); err != nil {
+		t.Fatalf("expected DisableActiveIPCheck to allow third-party host, got error: %v", err)
+	}
+}
@@ -27,13 +27,20 @@ type clientHandler struct {
 	ctxRnfr     string               // Rename from
 	ctxRest     int64                // Restart point
 	debug       bool                 // Show debugging info on the server side
-	transfer    transferHandler      // Transfer connection (only passive is implemented at this stage)
+	transfer    transferHandler      // Transfer connection (active or passive)
 	transferTLS bool                 // Use TLS for transfer connection
+	controlTLS  bool                 // Control connection has been upgraded to TLS (AUTH TLS/SSL)
 	logger      *logrus.Logger       // Client handler logging
+
+	proxyRemoteAddr net.Addr // Real client address, overridden by a PROXY protocol header
+	proxyLocalAddr  net.Addr // Real local address, overridden by a PROXY protocol header
+
+	mlstFacts map[string]bool // Facts enabled for MLST/MLSD via OPTS MLST, nil means "all of them"
 }
 
 // newClientHandler initializes a client handler when someone connects
 func (server *FtpServer) newClientHandler(connection net.Conn, id uint32) *clientHandler {
+	enableKeepAlive(connection, time.Duration(server.settings.ControlKeepAlivePeriod)*time.Second)
 
 	p := &clientHandler{
 		daddy:       server,
@@ -48,6 +55,22 @@ func (server *FtpServer) newClientHandler(connection net.Conn, id uint32) *clien
 
 	// Just respecting the existing logic here, this could be probably be dropped at some point
 
+	if server.settings.ProxyProtocol != ProxyProtocolDisabled {
+		var err error
+		if isTrustedProxySource(server.settings.TrustedProxies, connection.RemoteAddr()) {
+			err = p.readProxyProtocolHeader()
+		} else if server.settings.ProxyProtocol == ProxyProtocolRequired {
+			err = errors.New("PROXY protocol header required but source is not a trusted proxy")
+		}
+		if err != nil {
+			p.logger.WithField("error", err).Error("invalid PROXY protocol header")
+			if server.settings.ProxyProtocol == ProxyProtocolRequired {
+				p.conn.Close()
+				p.reader = nil
+			}
+		}
+	}
+
 	return p
 }
 
@@ -80,13 +103,21 @@ func (c *clientHandler) ID() uint32 {
 	return c.id
 }
 
-// RemoteAddr returns the remote network address.
+// RemoteAddr returns the remote network address, or the original client
+// address carried by a PROXY protocol header, if one was parsed.
 func (c *clientHandler) RemoteAddr() net.Addr {
+	if c.proxyRemoteAddr != nil {
+		return c.proxyRemoteAddr
+	}
 	return c.conn.RemoteAddr()
 }
 
-// LocalAddr returns the local network address.
+// LocalAddr returns the local network address, or the original destination
+// address carried by a PROXY protocol header, if one was parsed.
 func (c *clientHandler) LocalAddr() net.Addr {
+	if c.proxyLocalAddr != nil {
+		return c.proxyLocalAddr
+	}
 	return c.conn.LocalAddr()
 }
 
@@ -102,6 +133,12 @@ func (c *clientHandler) end() {
 func (c *clientHandler) HandleCommands() {
 	defer c.end()
 
+	if c.reader == nil {
+		// We were dropped during setup, e.g. a malformed PROXY protocol
+		// header on a connection that requires one.
+		return
+	}
+
 	if msg, err := c.daddy.driver.WelcomeUser(c); err == nil {
 		c.writeMessage(220, msg)
 	} else {
@@ -118,8 +155,9 @@ func (c *clientHandler) HandleCommands() {
 		}
 
 		// florent(2018-01-14): #58: IDLE timeout: Preparing the deadline before we read
-		if c.daddy.settings.IdleTimeout > 0 {
-			c.conn.SetDeadline(time.Now().Add(time.Duration(time.Second.Nanoseconds() * int64(c.daddy.settings.IdleTimeout))))
+		readTimeout := c.controlReadTimeout()
+		if readTimeout > 0 {
+			c.conn.SetReadDeadline(time.Now().Add(readTimeout))
 		}
 
 		line, err := c.reader.ReadString('\n')
@@ -132,7 +170,7 @@ func (c *clientHandler) HandleCommands() {
 					// We have to extend the deadline now
 					c.conn.SetDeadline(time.Now().Add(time.Minute))
 					c.logger.Info("client timeout")
-					c.writeMessage(421, fmt.Sprintf("command timeout (%d seconds): closing control connection", c.daddy.settings.IdleTimeout))
+					c.writeMessage(421, fmt.Sprintf("command timeout (%.0f seconds): closing control connection", readTimeout.Seconds()))
 					if err := c.writer.Flush(); err != nil {
 						c.logger.WithField("error", err).Error("Network flush error")
 					}
@@ -179,39 +217,66 @@ func (c *clientHandler) handleCommand(line string) {
 		return
 	}
 
+	if c.command == "USER" && !c.controlTLS && c.daddy.settings.TLSRequired {
+		c.writeMessage(530, "TLS is required before login, please use AUTH TLS")
+		return
+	}
+
 	// Let's prepare to recover in case there's a command error
 	defer func() {
 		if r := recover(); r != nil {
 			c.writeMessage(500, fmt.Sprintf("Internal error: %s", r))
 		}
 	}()
-	cmdDesc.Fn(c)
+	c.dispatch(cmdDesc)
 }
 
 func (c *clientHandler) writeLine(line string) {
 	if c.debug {
 		c.logger.WithField("line", line).Debug("FTP SEND")
 	}
+	if timeout := c.daddy.settings.ControlWriteTimeout; timeout > 0 {
+		c.conn.SetWriteDeadline(time.Now().Add(time.Duration(timeout) * time.Second))
+	}
 	c.writer.Write([]byte(line))
 	c.writer.Write([]byte("\r\n"))
 	c.writer.Flush()
 }
 
+// controlReadTimeout returns how long we should wait for the next command
+// line. Before login it's governed by LoginTimeout (if set), otherwise by
+// ControlReadTimeout, falling back to the legacy IdleTimeout setting.
+func (c *clientHandler) controlReadTimeout() time.Duration {
+	if c.driver == nil && c.daddy.settings.LoginTimeout > 0 {
+		return time.Duration(c.daddy.settings.LoginTimeout) * time.Second
+	}
+	if c.daddy.settings.ControlReadTimeout > 0 {
+		return time.Duration(c.daddy.settings.ControlReadTimeout) * time.Second
+	}
+	return time.Duration(c.daddy.settings.IdleTimeout) * time.Second
+}
+
 func (c *clientHandler) writeMessage(code int, message string) {
 	c.writeLine(fmt.Sprintf("%d %s", code, message))
 }
 
 func (c *clientHandler) TransferOpen() (net.Conn, error) {
 	if c.transfer == nil {
-		c.writeMessage(550, "No passive connection declared")
-		return nil, errors.New("no passive connection declared")
+		c.writeMessage(550, "No transfer connection declared")
+		return nil, errors.New("no transfer connection declared")
 	}
 	c.writeMessage(150, "Using transfer connection")
 	conn, err := c.transfer.Open()
-	if err == nil && c.debug {
+	if err != nil {
+		return nil, err
+	}
+	if timeout := c.daddy.settings.DataTransferTimeout; timeout > 0 {
+		conn = &deadlineRefreshingConn{Conn: conn, timeout: time.Duration(timeout) * time.Second}
+	}
+	if c.debug {
 		c.logger.WithFields(logrus.Fields{"remoteAddr": conn.RemoteAddr().String(), "localAddr": conn.LocalAddr().String()}).Debug("FTP Transfer connection opened")
 	}
-	return conn, err
+	return conn, nil
 }
 
 func (c *clientHandler) TransferClose() {
@@ -0,0 +1,27 @@
+package server
+
+import (
+	"net"
+	"time"
+)
+
+// deadlineRefreshingConn wraps a net.Conn and resets its deadline to
+// timeout from now before every Read/Write, instead of relying on a single
+// deadline set once when the connection was opened. This keeps
+// DataTransferTimeout acting as an idle timeout (no progress for timeout
+// seconds closes the connection) rather than a hard cap that would kill a
+// slow but otherwise healthy transfer.
+type deadlineRefreshingConn struct {
+	net.Conn
+	timeout time.Duration
+}
+
+func (c *deadlineRefreshingConn) Read(b []byte) (int, error) {
+	c.Conn.SetDeadline(time.Now().Add(c.timeout))
+	return c.Conn.Read(b)
+}
+
+func (c *deadlineRefreshingConn) Write(b []byte) (int, error) {
+	c.Conn.SetDeadline(time.Now().Add(c.timeout))
+	return c.Conn.Write(b)
+}
@@ -0,0 +1,44 @@
+package server
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+// deadlineRecordingConn records every deadline it's asked to set, without
+// needing a real network connection.
+type deadlineRecordingConn struct {
+	net.Conn
+	deadlines []time.Time
+}
+
+func (c *deadlineRecordingConn) SetDeadline(t time.Time) error {
+	c.deadlines = append(c.deadlines, t)
+	return nil
+}
+
+func (c *deadlineRecordingConn) Read(b []byte) (int, error)  { return len(b), nil }
+func (c *deadlineRecordingConn) Write(b []byte) (int, error) { return len(b), nil }
+
+func TestDeadlineRefreshingConnResetsOnReadAndWrite(t *testing.T) {
+	inner := &deadlineRecordingConn{}
+	conn := &deadlineRefreshingConn{Conn: inner, timeout: time.Second}
+
+	if _, err := conn.Read(make([]byte, 4)); err != nil {
+		t.Fatalf("Read returned error: %v", err)
+	}
+	if _, err := conn.Write([]byte("data")); err != nil {
+		t.Fatalf("Write returned error: %v", err)
+	}
+
+	if len(inner.deadlines) != 2 {
+		t.Fatalf("SetDeadline calls = %d, want 2 (one per Read/Write)", len(inner.deadlines))
+	}
+
+	for i, deadline := range inner.deadlines {
+		if time.Until(deadline) <= 0 || time.Until(deadline) > time.Second {
+			t.Fatalf("deadline[%d] = %v, want roughly now+timeout", i, deadline)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package server
+
+func init() {
+	commandsMap["FEAT"] = &CommandDescription{Open: true, Fn: handleFEAT}
+}
+
+// featProvider returns the FEAT lines a given part of the server wants to
+// advertise for this connection, or nil if the feature isn't usable here
+// (e.g. TLS support when no certificate is configured).
+type featProvider func(c *clientHandler) []string
+
+// featProviders is populated by init() in the files implementing each
+// optional extension (AUTH/PBSZ/PROT, MLST, ...).
+var featProviders []featProvider
+
+// handleFEAT replies with the extensions this server supports, per RFC 2389.
+func handleFEAT(c *clientHandler) {
+	c.writeLine("211-Features supported:")
+	for _, provider := range featProviders {
+		for _, line := range provider(c) {
+			c.writeLine(" " + line)
+		}
+	}
+	c.writeMessage(211, "End")
+}
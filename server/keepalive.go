@@ -0,0 +1,25 @@
+package server
+
+import (
+	"net"
+	"time"
+)
+
+// enableKeepAlive turns on TCP keepalives on the control connection, when
+// configured and when the underlying connection is a plain TCP one (e.g.
+// not already wrapped in TLS). This lets half-open connections behind NAT
+// or a stateful firewall be detected without having to rely on a short
+// idle timeout.
+func enableKeepAlive(conn net.Conn, period time.Duration) {
+	if period <= 0 {
+		return
+	}
+
+	tcpConn, ok := conn.(*net.TCPConn)
+	if !ok {
+		return
+	}
+
+	tcpConn.SetKeepAlive(true)
+	tcpConn.SetKeepAlivePeriod(period)
+}
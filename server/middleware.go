@@ -0,0 +1,50 @@
+package server
+
+import "net"
+
+// CommandFunc is the signature every FTP command handler implements.
+type CommandFunc func(c *clientHandler)
+
+// Context exposes the details of the command being dispatched to a
+// Middleware, without giving it free rein over the clientHandler itself.
+type Context struct {
+	Command    string
+	Param      string
+	User       string
+	RemoteAddr net.Addr
+}
+
+// Middleware wraps a CommandFunc to add cross-cutting behaviour (audit
+// logging, rate limiting, ACLs, metrics, ...) around every command
+// dispatched by handleCommand.
+type Middleware func(ctx *Context, next CommandFunc) CommandFunc
+
+// Use registers one or more middlewares, in the order they should run.
+// The first middleware registered is the outermost: it sees the command
+// before any other middleware, and can short-circuit the chain by not
+// calling next.
+func (server *FtpServer) Use(middlewares ...Middleware) {
+	server.middlewares = append(server.middlewares, middlewares...)
+}
+
+// dispatch runs cmdDesc.Fn through the registered middleware chain.
+func (c *clientHandler) dispatch(cmdDesc *CommandDescription) {
+	fn := CommandFunc(cmdDesc.Fn)
+
+	if len(c.daddy.middlewares) == 0 {
+		fn(c)
+		return
+	}
+
+	ctx := &Context{
+		Command:    c.command,
+		Param:      c.param,
+		User:       c.user,
+		RemoteAddr: c.RemoteAddr(),
+	}
+
+	for i := len(c.daddy.middlewares) - 1; i >= 0; i-- {
+		fn = c.daddy.middlewares[i](ctx, fn)
+	}
+	fn(c)
+}
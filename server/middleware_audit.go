@@ -0,0 +1,20 @@
+package server
+
+import "github.com/Sirupsen/logrus"
+
+// NewAuditMiddleware returns a Middleware that logs every command with
+// structured fields, giving operators an audit trail beyond the bare
+// "FTP RECV" debug line.
+func NewAuditMiddleware(logger *logrus.Logger) Middleware {
+	return func(ctx *Context, next CommandFunc) CommandFunc {
+		return func(c *clientHandler) {
+			logger.WithFields(logrus.Fields{
+				"command":    ctx.Command,
+				"param":      ctx.Param,
+				"user":       ctx.User,
+				"remoteAddr": ctx.RemoteAddr.String(),
+			}).Info("FTP command")
+			next(c)
+		}
+	}
+}
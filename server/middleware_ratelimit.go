@@ -0,0 +1,123 @@
+package server
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+// tokenBucket is a minimal token-bucket rate limiter, refilled continuously
+// based on elapsed time rather than on a ticker.
+type tokenBucket struct {
+	mu         sync.Mutex
+	tokens     float64
+	capacity   float64
+	refillRate float64 // tokens per second
+	updatedAt  time.Time
+}
+
+func newTokenBucket(capacity, refillRate float64) *tokenBucket {
+	return &tokenBucket{
+		tokens:     capacity,
+		capacity:   capacity,
+		refillRate: refillRate,
+		updatedAt:  time.Now(),
+	}
+}
+
+func (b *tokenBucket) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	b.tokens += now.Sub(b.updatedAt).Seconds() * b.refillRate
+	if b.tokens > b.capacity {
+		b.tokens = b.capacity
+	}
+	b.updatedAt = now
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// idleFor reports how long it's been since this bucket was last consulted,
+// used by rateLimiterBuckets to evict entries nobody is using anymore.
+func (b *tokenBucket) idleFor(now time.Time) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return now.Sub(b.updatedAt)
+}
+
+// rateLimitBucketTTL is how long a bucket may sit unused before it's
+// evicted from rateLimiterBuckets.
+const rateLimitBucketTTL = 10 * time.Minute
+
+// rateLimitSweepInterval bounds how often rateLimiterBuckets.get() scans
+// for stale buckets to evict, so the sweep cost doesn't show up on every
+// single command.
+const rateLimitSweepInterval = time.Minute
+
+// rateLimiterBuckets holds one tokenBucket per remote host, evicting
+// buckets that have sat idle past rateLimitBucketTTL so a server that
+// sees many distinct client IPs over time doesn't grow this map forever.
+type rateLimiterBuckets struct {
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSwept time.Time
+}
+
+func newRateLimiterBuckets() *rateLimiterBuckets {
+	return &rateLimiterBuckets{
+		buckets:   map[string]*tokenBucket{},
+		lastSwept: time.Now(),
+	}
+}
+
+// get returns the bucket for host, creating one if needed, and
+// opportunistically sweeps stale entries out of the map first.
+func (r *rateLimiterBuckets) get(host string, capacity, refillRate float64) *tokenBucket {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(r.lastSwept) >= rateLimitSweepInterval {
+		for h, bucket := range r.buckets {
+			if bucket.idleFor(now) > rateLimitBucketTTL {
+				delete(r.buckets, h)
+			}
+		}
+		r.lastSwept = now
+	}
+
+	bucket, ok := r.buckets[host]
+	if !ok {
+		bucket = newTokenBucket(capacity, refillRate)
+		r.buckets[host] = bucket
+	}
+	return bucket
+}
+
+// NewRateLimitMiddleware returns a Middleware that limits how many
+// commands per second a single remote IP may issue, using an independent
+// token bucket per address.
+func NewRateLimitMiddleware(commandsPerSecond, burst float64) Middleware {
+	buckets := newRateLimiterBuckets()
+
+	return func(ctx *Context, next CommandFunc) CommandFunc {
+		return func(c *clientHandler) {
+			host, _, err := net.SplitHostPort(ctx.RemoteAddr.String())
+			if err != nil {
+				host = ctx.RemoteAddr.String()
+			}
+
+			if !buckets.get(host, burst, commandsPerSecond).allow() {
+				c.writeMessage(421, "Rate limit exceeded, please slow down")
+				return
+			}
+			next(c)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package server
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTokenBucketAllow(t *testing.T) {
+	b := newTokenBucket(2, 1) // burst of 2, refill 1/sec
+
+	if !b.allow() {
+		t.Fatal("expected first request to be allowed")
+	}
+	if !b.allow() {
+		t.Fatal("expected second request (within burst) to be allowed")
+	}
+	if b.allow() {
+		t.Fatal("expected third request to be rate limited")
+	}
+}
+
+func TestTokenBucketRefill(t *testing.T) {
+	b := newTokenBucket(1, 1000) // refill fast enough to observe within the test
+
+	if !b.allow() {
+		t.Fatal("expected first request to be allowed")
+	}
+	if b.allow() {
+		t.Fatal("expected immediate second request to be rate limited")
+	}
+
+	time.Sleep(5 * time.Millisecond)
+
+	if !b.allow() {
+		t.Fatal("expected request to be allowed after refill")
+	}
+}
+
+func TestRateLimiterBucketsGetReusesExistingBucket(t *testing.T) {
+	buckets := newRateLimiterBuckets()
+
+	first := buckets.get("203.0.113.5", 2, 1)
+	second := buckets.get("203.0.113.5", 2, 1)
+
+	if first != second {
+		t.Fatal("expected get() to return the same bucket for the same host")
+	}
+}
+
+func TestRateLimiterBucketsEvictsStaleEntries(t *testing.T) {
+	buckets := newRateLimiterBuckets()
+
+	stale := buckets.get("203.0.113.5", 2, 1)
+	stale.updatedAt = time.Now().Add(-2 * rateLimitBucketTTL)
+	// Force the next get() to run its sweep regardless of how recently
+	// the test itself started.
+	buckets.lastSwept = time.Now().Add(-2 * rateLimitSweepInterval)
+
+	buckets.get("198.51.100.9", 2, 1)
+
+	buckets.mu.Lock()
+	_, stillPresent := buckets.buckets["203.0.113.5"]
+	buckets.mu.Unlock()
+
+	if stillPresent {
+		t.Fatal("expected a bucket idle past rateLimitBucketTTL to be evicted")
+	}
+}
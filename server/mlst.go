@@ -0,0 +1,154 @@
+package server
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+)
+
+// allMLSTFacts lists every fact we know how to produce, in the order they
+// should appear in a listing.
+var allMLSTFacts = []string{"type", "size", "modify", "perm", "unique"}
+
+func init() {
+	commandsMap["MLST"] = &CommandDescription{Fn: handleMLST}
+	commandsMap["MLSD"] = &CommandDescription{Fn: handleMLSD}
+	commandsMap["OPTS"] = &CommandDescription{Fn: handleOPTS}
+	featProviders = append(featProviders, mlstFeatLines)
+}
+
+// mlstFeatLines advertises every fact this server knows how to produce,
+// per RFC 3659: the ones currently enabled for this session are marked
+// with a trailing "*", but the full set is always listed, even facts the
+// client has since disabled via OPTS MLST.
+func mlstFeatLines(c *clientHandler) []string {
+	enabled := map[string]bool{}
+	for _, fact := range c.enabledMLSTFacts() {
+		enabled[fact] = true
+	}
+
+	marked := make([]string, len(allMLSTFacts))
+	for i, fact := range allMLSTFacts {
+		if enabled[fact] {
+			fact += "*"
+		}
+		marked[i] = fact
+	}
+	return []string{"MLST " + strings.Join(marked, ";") + ";"}
+}
+
+// enabledMLSTFacts returns the facts this client wants in MLST/MLSD
+// listings, defaulting to all of them until OPTS MLST narrows the set.
+func (c *clientHandler) enabledMLSTFacts() []string {
+	if c.mlstFacts == nil {
+		return allMLSTFacts
+	}
+	facts := make([]string, 0, len(allMLSTFacts))
+	for _, fact := range allMLSTFacts {
+		if c.mlstFacts[fact] {
+			facts = append(facts, fact)
+		}
+	}
+	return facts
+}
+
+// handleOPTS implements the generic OPTS command. The only option this
+// server recognizes today is MLST, which lets a client pick which facts
+// it wants in subsequent MLST/MLSD listings.
+func handleOPTS(c *clientHandler) {
+	fields := strings.SplitN(c.param, " ", 2)
+	if len(fields) != 2 || strings.ToUpper(fields[0]) != "MLST" {
+		c.writeMessage(501, "Unsupported OPTS option")
+		return
+	}
+
+	selected := map[string]bool{}
+	for _, fact := range strings.Split(fields[1], ";") {
+		fact = strings.ToLower(strings.TrimSpace(fact))
+		for _, known := range allMLSTFacts {
+			if fact == known {
+				selected[fact] = true
+			}
+		}
+	}
+
+	c.mlstFacts = selected
+	c.writeMessage(200, "OPTS MLST "+strings.Join(c.enabledMLSTFacts(), ";")+";")
+}
+
+// handleMLST implements RFC 3659 MLST: a single machine-parseable listing
+// entry for the given path, or the current directory if none is given.
+func handleMLST(c *clientHandler) {
+	p := c.param
+	if p == "" {
+		p = c.path
+	}
+
+	info, err := c.driver.Stat(p)
+	if err != nil {
+		c.writeMessage(550, "Could not stat "+p+": "+err.Error())
+		return
+	}
+
+	c.writeLine("250-Listing " + p)
+	c.writeLine(" " + c.formatMLSTEntry(p, info))
+	c.writeMessage(250, "End")
+}
+
+// handleMLSD implements RFC 3659 MLSD: the same per-entry format as MLST,
+// but for every entry of a directory, streamed over the data connection.
+func handleMLSD(c *clientHandler) {
+	p := c.param
+	if p == "" {
+		p = c.path
+	}
+
+	entries, err := c.driver.ReadDir(p)
+	if err != nil {
+		c.writeMessage(550, "Could not list "+p+": "+err.Error())
+		return
+	}
+
+	conn, err := c.TransferOpen()
+	if err != nil {
+		return
+	}
+	defer c.TransferClose()
+
+	for _, info := range entries {
+		fmt.Fprintf(conn, "%s\r\n", c.formatMLSTEntry(path.Join(p, info.Name()), info))
+	}
+}
+
+// formatMLSTEntry renders a single "facts filename" line as described by
+// RFC 3659, honoring the facts this client has enabled via OPTS MLST.
+func (c *clientHandler) formatMLSTEntry(fullPath string, info os.FileInfo) string {
+	var b strings.Builder
+	for _, fact := range c.enabledMLSTFacts() {
+		switch fact {
+		case "type":
+			if info.IsDir() {
+				b.WriteString("type=dir;")
+			} else {
+				b.WriteString("type=file;")
+			}
+		case "size":
+			fmt.Fprintf(&b, "size=%d;", info.Size())
+		case "modify":
+			fmt.Fprintf(&b, "modify=%s;", info.ModTime().UTC().Format("20060102150405"))
+		case "perm":
+			if info.IsDir() {
+				b.WriteString("perm=el;")
+			} else {
+				b.WriteString("perm=r;")
+			}
+		case "unique":
+			if id, err := c.driver.GetUniqueID(fullPath); err == nil {
+				fmt.Fprintf(&b, "unique=%s;", id)
+			}
+		}
+	}
+	fmt.Fprintf(&b, " %s", path.Base(fullPath))
+	return b.String()
+}
@@ -0,0 +1,127 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeFileInfo is a minimal os.FileInfo for exercising formatMLSTEntry
+// without needing a real filesystem entry.
+type fakeFileInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+	isDir   bool
+}
+
+func (f fakeFileInfo) Name() string       { return f.name }
+func (f fakeFileInfo) Size() int64        { return f.size }
+func (f fakeFileInfo) Mode() os.FileMode  { return 0 }
+func (f fakeFileInfo) ModTime() time.Time { return f.modTime }
+func (f fakeFileInfo) IsDir() bool        { return f.isDir }
+func (f fakeFileInfo) Sys() interface{}   { return nil }
+
+func newOptsTestHandler() (*clientHandler, *bytes.Buffer) {
+	var buf bytes.Buffer
+	c := &clientHandler{
+		daddy:  &FtpServer{settings: Settings{}},
+		writer: bufio.NewWriter(&buf),
+	}
+	return c, &buf
+}
+
+func TestMLSTFeatLinesListsFullFactSet(t *testing.T) {
+	c := &clientHandler{mlstFacts: map[string]bool{"size": true, "modify": true}}
+
+	lines := mlstFeatLines(c)
+	if len(lines) != 1 {
+		t.Fatalf("mlstFeatLines returned %d lines, want 1", len(lines))
+	}
+
+	for _, fact := range allMLSTFacts {
+		if !strings.Contains(lines[0], fact) {
+			t.Fatalf("feat line %q is missing fact %q", lines[0], fact)
+		}
+	}
+	if !strings.Contains(lines[0], "size*") || !strings.Contains(lines[0], "modify*") {
+		t.Fatalf("feat line %q should mark enabled facts with *", lines[0])
+	}
+	if strings.Contains(lines[0], "type*") || strings.Contains(lines[0], "perm*") {
+		t.Fatalf("feat line %q should not mark disabled facts with *", lines[0])
+	}
+}
+
+func TestHandleOPTSSelectsKnownFacts(t *testing.T) {
+	c, buf := newOptsTestHandler()
+	c.param = "MLST size;modify;bogus"
+
+	handleOPTS(c)
+
+	want := map[string]bool{"size": true, "modify": true}
+	if len(c.mlstFacts) != len(want) {
+		t.Fatalf("mlstFacts = %v, want %v", c.mlstFacts, want)
+	}
+	for fact := range want {
+		if !c.mlstFacts[fact] {
+			t.Fatalf("mlstFacts = %v, missing %q", c.mlstFacts, fact)
+		}
+	}
+	if c.mlstFacts["bogus"] {
+		t.Fatalf("mlstFacts = %v, should not contain unknown fact", c.mlstFacts)
+	}
+	if !strings.Contains(buf.String(), "200 ") {
+		t.Fatalf("response = %q, want a 200 reply", buf.String())
+	}
+}
+
+func TestHandleOPTSRejectsUnsupportedOption(t *testing.T) {
+	c, buf := newOptsTestHandler()
+	c.param = "UTF8 ON"
+
+	handleOPTS(c)
+
+	if !strings.Contains(buf.String(), "501 ") {
+		t.Fatalf("response = %q, want a 501 reply", buf.String())
+	}
+}
+
+func TestFormatMLSTEntry(t *testing.T) {
+	c := &clientHandler{mlstFacts: map[string]bool{"type": true, "size": true, "modify": true, "perm": true}}
+	info := fakeFileInfo{name: "report.txt", size: 42, modTime: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)}
+
+	entry := c.formatMLSTEntry("/home/report.txt", info)
+
+	if !strings.Contains(entry, "type=file;") {
+		t.Fatalf("entry = %q, want type=file", entry)
+	}
+	if !strings.Contains(entry, "size=42;") {
+		t.Fatalf("entry = %q, want size=42", entry)
+	}
+	if !strings.Contains(entry, "modify=20260102030405;") {
+		t.Fatalf("entry = %q, want modify=20260102030405", entry)
+	}
+	if !strings.Contains(entry, "perm=r;") {
+		t.Fatalf("entry = %q, want perm=r", entry)
+	}
+	if !strings.HasSuffix(entry, " report.txt") {
+		t.Fatalf("entry = %q, want a trailing filename", entry)
+	}
+}
+
+func TestFormatMLSTEntryDirectory(t *testing.T) {
+	c := &clientHandler{mlstFacts: map[string]bool{"type": true, "perm": true}}
+	info := fakeFileInfo{name: "uploads", isDir: true}
+
+	entry := c.formatMLSTEntry("/home/uploads", info)
+
+	if !strings.Contains(entry, "type=dir;") {
+		t.Fatalf("entry = %q, want type=dir", entry)
+	}
+	if !strings.Contains(entry, "perm=el;") {
+		t.Fatalf("entry = %q, want perm=el", entry)
+	}
+}
@@ -0,0 +1,195 @@
+package server
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// proxyProtocolHeaderTimeout bounds how long we'll wait for a PROXY
+// protocol header before giving up. Without it, a direct (non-proxied)
+// client in ProxyProtocolOptional mode would have the server block here
+// forever: FTP is server-speaks-first, so such a client never writes
+// anything until it sees our 220 banner.
+const proxyProtocolHeaderTimeout = 1 * time.Second
+
+// proxyProtocolV1MaxLen is the largest a v1 text header can be, per spec.
+const proxyProtocolV1MaxLen = 107
+
+// ProxyProtocolMode controls whether/how this server accepts the PROXY
+// protocol (v1/v2) on new control connections, for deployments that sit
+// behind an L4 load balancer (HAProxy, AWS NLB, ...).
+type ProxyProtocolMode int
+
+const (
+	// ProxyProtocolDisabled never looks for a PROXY protocol header.
+	ProxyProtocolDisabled ProxyProtocolMode = iota
+	// ProxyProtocolOptional parses a PROXY protocol header if present,
+	// but tolerates connections that don't send one.
+	ProxyProtocolOptional
+	// ProxyProtocolRequired rejects any connection that doesn't start
+	// with a valid PROXY protocol header.
+	ProxyProtocolRequired
+)
+
+var proxyProtocolV2Signature = [12]byte{0x0D, 0x0A, 0x0D, 0x0A, 0x00, 0x0D, 0x0A, 0x51, 0x55, 0x49, 0x54, 0x0A}
+
+// isTrustedProxySource reports whether addr belongs to one of the
+// configured trusted proxy CIDRs/IPs. A PROXY protocol header must only be
+// honored from a trusted source: otherwise any client could simply send its
+// own PROXY header to spoof the address the bounce check in
+// validateActiveTarget and the rate limiter key on.
+func isTrustedProxySource(trusted []string, addr net.Addr) bool {
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		host = addr.String()
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+
+	for _, entry := range trusted {
+		if _, cidr, err := net.ParseCIDR(entry); err == nil {
+			if cidr.Contains(ip) {
+				return true
+			}
+			continue
+		}
+		if trustedIP := net.ParseIP(entry); trustedIP != nil && trustedIP.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// readProxyProtocolHeader peeks at the start of the connection looking for
+// a PROXY protocol v1 or v2 header and, if found, overrides the addresses
+// reported by RemoteAddr()/LocalAddr() with the ones it carries. This
+// happens before HandleCommands starts reading FTP commands, so it's
+// entirely transparent to the command loop and the driver.
+func (c *clientHandler) readProxyProtocolHeader() error {
+	c.conn.SetReadDeadline(time.Now().Add(proxyProtocolHeaderTimeout))
+	defer c.conn.SetReadDeadline(time.Time{})
+
+	peeked, err := c.reader.Peek(len(proxyProtocolV2Signature))
+	if err == nil && bytes.Equal(peeked, proxyProtocolV2Signature[:]) {
+		return c.readProxyProtocolV2()
+	}
+	return c.readProxyProtocolV1()
+}
+
+// readProxyProtocolV1 parses the human-readable header, e.g.
+// "PROXY TCP4 192.0.2.1 198.51.100.1 35000 21\r\n", or the minimal
+// "PROXY UNKNOWN\r\n" health-check form.
+//
+// It grows its Peek() one byte at a time instead of asking for the full
+// proxyProtocolV1MaxLen up front: Peek only touches the network when it
+// needs bytes beyond what's already buffered, so the common case (the
+// whole header arriving in a single read) resolves against the buffer we
+// already have, without waiting out the deadline for bytes nobody is
+// going to send.
+func (c *clientHandler) readProxyProtocolV1() error {
+	var buf []byte
+	var err error
+
+	for n := 1; n <= proxyProtocolV1MaxLen; n++ {
+		buf, err = c.reader.Peek(n)
+		if idx := bytes.Index(buf, []byte("\r\n")); idx >= 0 {
+			return c.parseProxyProtocolV1Line(string(buf[:idx]))
+		}
+		if err != nil {
+			break
+		}
+	}
+
+	if len(buf) == 0 {
+		return fmt.Errorf("reading PROXY v1 header: %w", err)
+	}
+	return fmt.Errorf("no PROXY v1 header found")
+}
+
+// parseProxyProtocolV1Line parses and, only once confirmed valid,
+// Discards the header line (including its trailing CRLF) from the reader.
+// On any error nothing is consumed, so a malformed or absent header
+// leaves the stream untouched for the normal FTP command loop to read.
+func (c *clientHandler) parseProxyProtocolV1Line(line string) error {
+	fields := strings.Fields(line)
+	if len(fields) < 2 || fields[0] != "PROXY" {
+		return fmt.Errorf("malformed PROXY v1 header: %q", line)
+	}
+
+	if fields[1] == "UNKNOWN" {
+		c.reader.Discard(len(line) + 2)
+		return nil
+	}
+
+	if len(fields) != 6 {
+		return fmt.Errorf("malformed PROXY v1 header: %q", line)
+	}
+
+	srcPort, err := strconv.Atoi(fields[4])
+	if err != nil {
+		return fmt.Errorf("invalid source port in PROXY v1 header: %w", err)
+	}
+	dstPort, err := strconv.Atoi(fields[5])
+	if err != nil {
+		return fmt.Errorf("invalid destination port in PROXY v1 header: %w", err)
+	}
+
+	srcIP, dstIP := net.ParseIP(fields[2]), net.ParseIP(fields[3])
+	if srcIP == nil || dstIP == nil {
+		return fmt.Errorf("invalid address in PROXY v1 header: %q", line)
+	}
+
+	c.reader.Discard(len(line) + 2)
+	c.proxyRemoteAddr = &net.TCPAddr{IP: srcIP, Port: srcPort}
+	c.proxyLocalAddr = &net.TCPAddr{IP: dstIP, Port: dstPort}
+	return nil
+}
+
+// readProxyProtocolV2 parses the binary header: a 12-byte signature, a
+// 4-byte header carrying the command/family/protocol and body length, then
+// the address block itself.
+func (c *clientHandler) readProxyProtocolV2() error {
+	header := make([]byte, 16)
+	if _, err := io.ReadFull(c.reader, header); err != nil {
+		return fmt.Errorf("reading PROXY v2 header: %w", err)
+	}
+
+	length := binary.BigEndian.Uint16(header[14:16])
+	body := make([]byte, length)
+	if _, err := io.ReadFull(c.reader, body); err != nil {
+		return fmt.Errorf("reading PROXY v2 body: %w", err)
+	}
+
+	if command := header[12] & 0x0F; command == 0x00 {
+		// LOCAL: health check from the proxy itself, keep the real addresses.
+		return nil
+	}
+
+	switch family := header[13] >> 4; family {
+	case 0x1: // AF_INET
+		if len(body) < 12 {
+			return fmt.Errorf("PROXY v2 IPv4 body too short")
+		}
+		c.proxyRemoteAddr = &net.TCPAddr{IP: net.IP(body[0:4]), Port: int(binary.BigEndian.Uint16(body[8:10]))}
+		c.proxyLocalAddr = &net.TCPAddr{IP: net.IP(body[4:8]), Port: int(binary.BigEndian.Uint16(body[10:12]))}
+	case 0x2: // AF_INET6
+		if len(body) < 36 {
+			return fmt.Errorf("PROXY v2 IPv6 body too short")
+		}
+		c.proxyRemoteAddr = &net.TCPAddr{IP: net.IP(body[0:16]), Port: int(binary.BigEndian.Uint16(body[32:34]))}
+		c.proxyLocalAddr = &net.TCPAddr{IP: net.IP(body[16:32]), Port: int(binary.BigEndian.Uint16(body[34:36]))}
+	default:
+		return fmt.Errorf("unsupported PROXY v2 address family %d", family)
+	}
+
+	return nil
+}
@@ -0,0 +1,136 @@
+package server
+
+import (
+	"bufio"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+)
+
+func newProxyProtocolTestHandler() (*clientHandler, net.Conn) {
+	serverConn, clientConn := net.Pipe()
+	c := &clientHandler{
+		conn:   serverConn,
+		reader: bufio.NewReader(serverConn),
+	}
+	return c, clientConn
+}
+
+func TestIsTrustedProxySource(t *testing.T) {
+	trusted := []string{"10.0.0.0/8", "198.51.100.7"}
+
+	cases := []struct {
+		name string
+		addr net.Addr
+		want bool
+	}{
+		{name: "matches CIDR", addr: &net.TCPAddr{IP: net.ParseIP("10.1.2.3"), Port: 4242}, want: true},
+		{name: "matches exact IP", addr: &net.TCPAddr{IP: net.ParseIP("198.51.100.7"), Port: 4242}, want: true},
+		{name: "untrusted", addr: &net.TCPAddr{IP: net.ParseIP("203.0.113.9"), Port: 4242}, want: false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := isTrustedProxySource(trusted, tc.addr); got != tc.want {
+				t.Fatalf("isTrustedProxySource(%v) = %v, want %v", tc.addr, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestReadProxyProtocolV1(t *testing.T) {
+	c, clientConn := newProxyProtocolTestHandler()
+	defer clientConn.Close()
+
+	go clientConn.Write([]byte("PROXY TCP4 192.0.2.1 198.51.100.1 35000 21\r\n"))
+
+	if err := c.readProxyProtocolHeader(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := c.proxyRemoteAddr.String(); got != "192.0.2.1:35000" {
+		t.Fatalf("remote addr = %q, want 192.0.2.1:35000", got)
+	}
+	if got := c.proxyLocalAddr.String(); got != "198.51.100.1:21" {
+		t.Fatalf("local addr = %q, want 198.51.100.1:21", got)
+	}
+
+	// The bytes that follow the header must still be intact for the
+	// normal FTP command loop to read.
+	go clientConn.Write([]byte("NOOP\r\n"))
+	line, err := c.reader.ReadString('\n')
+	if err != nil || line != "NOOP\r\n" {
+		t.Fatalf("trailing command = %q, err=%v, want \"NOOP\\r\\n\"", line, err)
+	}
+}
+
+func TestReadProxyProtocolV1Unknown(t *testing.T) {
+	c, clientConn := newProxyProtocolTestHandler()
+	defer clientConn.Close()
+
+	go clientConn.Write([]byte("PROXY UNKNOWN\r\n"))
+
+	if err := c.readProxyProtocolHeader(); err != nil {
+		t.Fatalf("unexpected error for PROXY UNKNOWN: %v", err)
+	}
+	if c.proxyRemoteAddr != nil || c.proxyLocalAddr != nil {
+		t.Fatal("PROXY UNKNOWN must not override the real addresses")
+	}
+}
+
+func TestReadProxyProtocolV2(t *testing.T) {
+	c, clientConn := newProxyProtocolTestHandler()
+	defer clientConn.Close()
+
+	body := make([]byte, 12)
+	copy(body[0:4], net.ParseIP("192.0.2.1").To4())
+	copy(body[4:8], net.ParseIP("198.51.100.1").To4())
+	binary.BigEndian.PutUint16(body[8:10], 35000)
+	binary.BigEndian.PutUint16(body[10:12], 21)
+
+	header := append([]byte{}, proxyProtocolV2Signature[:]...)
+	header = append(header, 0x21, 0x11) // version 2, PROXY command, AF_INET/STREAM
+	lenBuf := make([]byte, 2)
+	binary.BigEndian.PutUint16(lenBuf, uint16(len(body)))
+	header = append(header, lenBuf...)
+	header = append(header, body...)
+
+	go clientConn.Write(header)
+
+	if err := c.readProxyProtocolHeader(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := c.proxyRemoteAddr.String(); got != "192.0.2.1:35000" {
+		t.Fatalf("remote addr = %q, want 192.0.2.1:35000", got)
+	}
+	if got := c.proxyLocalAddr.String(); got != "198.51.100.1:21" {
+		t.Fatalf("local addr = %q, want 198.51.100.1:21", got)
+	}
+}
+
+func TestReadProxyProtocolHeaderTimesOutWithoutConsuming(t *testing.T) {
+	c, clientConn := newProxyProtocolTestHandler()
+	defer clientConn.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- c.readProxyProtocolHeader()
+	}()
+
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("expected an error for a connection that never sends a PROXY header")
+		}
+	case <-time.After(proxyProtocolHeaderTimeout + 2*time.Second):
+		t.Fatal("readProxyProtocolHeader blocked past its deadline")
+	}
+
+	// A direct client's first real command line must still be readable,
+	// i.e. nothing was silently consumed while looking for a header.
+	go clientConn.Write([]byte("USER anonymous\r\n"))
+	line, err := c.reader.ReadString('\n')
+	if err != nil || line != "USER anonymous\r\n" {
+		t.Fatalf("command after failed header parse = %q, err=%v, want \"USER anonymous\\r\\n\"", line, err)
+	}
+}
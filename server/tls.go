@@ -0,0 +1,90 @@
+package server
+
+import (
+	"bufio"
+	"crypto/tls"
+	"strings"
+)
+
+func init() {
+	commandsMap["AUTH"] = &CommandDescription{Open: true, Fn: handleAUTH}
+	commandsMap["PBSZ"] = &CommandDescription{Open: true, Fn: handlePBSZ}
+	commandsMap["PROT"] = &CommandDescription{Open: true, Fn: handlePROT}
+	featProviders = append(featProviders, tlsFeatLines)
+}
+
+// tlsFeatLines advertises AUTH TLS/SSL, PBSZ and PROT, but only when the
+// driver actually hands us a TLS configuration to use.
+func tlsFeatLines(c *clientHandler) []string {
+	if c.getTLSConfig() == nil {
+		return nil
+	}
+	return []string{"AUTH TLS", "AUTH SSL", "PBSZ", "PROT"}
+}
+
+// getTLSConfig asks the driver for the TLS configuration to use for this
+// connection. A nil result means TLS isn't configured on this server.
+func (c *clientHandler) getTLSConfig() *tls.Config {
+	conf, err := c.daddy.driver.GetTLSConfig()
+	if err != nil || conf == nil {
+		return nil
+	}
+	return conf
+}
+
+// handleAUTH implements RFC 4217 explicit FTPS: AUTH TLS and AUTH SSL
+// upgrade the control connection to TLS in place.
+func handleAUTH(c *clientHandler) {
+	conf := c.getTLSConfig()
+	if conf == nil {
+		c.writeMessage(502, "TLS is not configured on this server")
+		return
+	}
+
+	switch strings.ToUpper(c.param) {
+	case "TLS", "SSL":
+	default:
+		c.writeMessage(504, "Unsupported auth type "+c.param)
+		return
+	}
+
+	c.writeMessage(234, "AUTH command ok. Expecting TLS negotiation")
+
+	tlsConn := tls.Server(c.conn, conf)
+	if err := tlsConn.Handshake(); err != nil {
+		c.logger.WithField("error", err).Error("TLS handshake failed")
+		c.conn.Close()
+		return
+	}
+
+	c.conn = tlsConn
+	c.reader = bufio.NewReader(tlsConn)
+	c.writer = bufio.NewWriter(tlsConn)
+	c.controlTLS = true
+}
+
+// handlePBSZ implements the mandatory companion to AUTH TLS. Since we only
+// ever use streaming TLS data connections, the buffer size is always 0.
+func handlePBSZ(c *clientHandler) {
+	c.writeMessage(200, "PBSZ=0")
+}
+
+// handlePROT selects whether the data connection is protected (P, TLS) or
+// left in the clear (C). It requires PBSZ to have been sent first, per
+// RFC 4217, but we don't track that strictly since we only support PBSZ 0.
+func handlePROT(c *clientHandler) {
+	switch strings.ToUpper(c.param) {
+	case "C":
+		c.transferTLS = false
+		c.writeMessage(200, "Protection set to Clear")
+	case "P":
+		if c.getTLSConfig() == nil {
+			c.writeMessage(431, "TLS is not configured on this server")
+			return
+		}
+		c.transferTLS = true
+		c.writeMessage(200, "Protection set to Private")
+	default:
+		c.writeMessage(504, "Unsupported protection type "+c.param)
+	}
+}
@@ -0,0 +1,114 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// fakeTLSDriver implements MainDriver, returning whatever TLS config (or
+// error) the test configures, so handlePROT/AUTH can be exercised without a
+// real certificate.
+type fakeTLSDriver struct {
+	conf *tls.Config
+	err  error
+}
+
+func (d fakeTLSDriver) WelcomeUser(c *clientHandler) (string, error) { return "", nil }
+func (d fakeTLSDriver) UserLeft(c *clientHandler)                    {}
+func (d fakeTLSDriver) GetTLSConfig() (*tls.Config, error)           { return d.conf, d.err }
+
+func newTLSTestHandler(driver MainDriver) (*clientHandler, *bytes.Buffer) {
+	var buf bytes.Buffer
+	c := &clientHandler{
+		daddy:  &FtpServer{settings: Settings{}, driver: driver},
+		writer: bufio.NewWriter(&buf),
+	}
+	return c, &buf
+}
+
+func TestHandlePBSZAlwaysOK(t *testing.T) {
+	c, buf := newTLSTestHandler(fakeTLSDriver{})
+
+	handlePBSZ(c)
+
+	if !strings.Contains(buf.String(), "200 PBSZ=0") {
+		t.Fatalf("response = %q, want 200 PBSZ=0", buf.String())
+	}
+}
+
+func TestHandlePROTClear(t *testing.T) {
+	c, buf := newTLSTestHandler(fakeTLSDriver{})
+	c.transferTLS = true
+	c.param = "c"
+
+	handlePROT(c)
+
+	if c.transferTLS {
+		t.Fatal("expected PROT C to clear transferTLS")
+	}
+	if !strings.Contains(buf.String(), "200 ") {
+		t.Fatalf("response = %q, want a 200 reply", buf.String())
+	}
+}
+
+func TestHandlePROTPrivateRequiresTLSConfig(t *testing.T) {
+	c, buf := newTLSTestHandler(fakeTLSDriver{err: errors.New("no cert configured")})
+	c.param = "p"
+
+	handlePROT(c)
+
+	if c.transferTLS {
+		t.Fatal("expected transferTLS to stay false without a TLS config")
+	}
+	if !strings.Contains(buf.String(), "431 ") {
+		t.Fatalf("response = %q, want a 431 reply", buf.String())
+	}
+}
+
+func TestHandlePROTPrivate(t *testing.T) {
+	c, buf := newTLSTestHandler(fakeTLSDriver{conf: &tls.Config{}})
+	c.param = "P"
+
+	handlePROT(c)
+
+	if !c.transferTLS {
+		t.Fatal("expected PROT P to enable transferTLS")
+	}
+	if !strings.Contains(buf.String(), "200 ") {
+		t.Fatalf("response = %q, want a 200 reply", buf.String())
+	}
+}
+
+func TestHandlePROTUnsupportedType(t *testing.T) {
+	c, buf := newTLSTestHandler(fakeTLSDriver{conf: &tls.Config{}})
+	c.param = "E"
+
+	handlePROT(c)
+
+	if !strings.Contains(buf.String(), "504 ") {
+		t.Fatalf("response = %q, want a 504 reply", buf.String())
+	}
+}
+
+func TestTLSFeatLinesRequireConfiguredTLS(t *testing.T) {
+	c, _ := newTLSTestHandler(fakeTLSDriver{err: errors.New("no cert configured")})
+	if lines := tlsFeatLines(c); lines != nil {
+		t.Fatalf("tlsFeatLines = %v, want nil without a TLS config", lines)
+	}
+
+	c2, _ := newTLSTestHandler(fakeTLSDriver{conf: &tls.Config{}})
+	lines := tlsFeatLines(c2)
+	want := []string{"AUTH TLS", "AUTH SSL", "PBSZ", "PROT"}
+	if len(lines) != len(want) {
+		t.Fatalf("tlsFeatLines = %v, want %v", lines, want)
+	}
+	for i, line := range want {
+		if lines[i] != line {
+			t.Fatalf("tlsFeatLines[%d] = %q, want %q", i, lines[i], line)
+		}
+	}
+}